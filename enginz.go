@@ -10,25 +10,37 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/fcgi"
 	"os"
-	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 type HandlerFunc http.HandlerFunc
 
 // Service defines the Service Endpoint for web serving
 type Service struct {
-	// Addr - the address to serve on. eg. ":80"
+	// Addr - the address to serve on. eg. ":80", or a path for a unix socket.
 	Addr string
+	// Network selects the listener + transport for this Service:
+	// "" or "tcp" - plain HTTP over tcp (the default)
+	// "unix"      - plain HTTP over a unix socket
+	// "fcgi-tcp"  - FastCGI over tcp
+	// "fcgi-unix" - FastCGI over a unix socket
+	Network string
 	// TLSConfig - a tls.Config for serving TLS.
 	TLSConfig *tls.Config
 	// TLSKey, TLSCert - files containing TLS key + cert
 	TLSKey  string
 	TLSCert string
 	www     http.Server
+	ln      net.Listener
 }
 
 // Reporter interface for reporting serious problems.
@@ -62,6 +74,24 @@ type Server struct {
 	Service []Service
 	// AccessLog specifies the file to use for logging. leave empty for none.
 	AccessLog string
+	// AccessLogWriter, if set, is used instead of opening AccessLog as a file.
+	AccessLogWriter io.Writer
+	// AccessLogFunc, if set, is called with each Collect directly,
+	// instead of formatting + writing it to AccessLog/AccessLogWriter.
+	AccessLogFunc func(*Collect)
+	// LogFormat selects the access log format: "combined" (the
+	// default), "json", or "custom" (see LogTemplate). it has no
+	// effect when AccessLogFunc is used.
+	LogFormat string
+	// LogTemplate is an apache/nginx-style format string, used when
+	// LogFormat is "custom". recognized tokens: %h (remote addr), %t
+	// (time), %v (host), %m (method), %U (uri), %r (request line), %s
+	// (status), %b (size), %D (duration, us), %{Header}i (request
+	// header), %% (literal %).
+	LogTemplate string
+	// LogFields names additional request headers to include in each
+	// access log entry (as extra fields in "json", ignored otherwise).
+	LogFields []string
 	ErrorLog  string
 	Log       Logger
 	// Handler specifies a standard http.Handler. required
@@ -72,9 +102,26 @@ type Server struct {
 	Error500 HandlerFunc // Error500 specifies a http.HandlerFunc for generating 500 server errors
 	Report   Reporter    // Report specifies an error Reporter
 	Collect  Collector   // Collect specifies a stats Collector
+	// LameDuck specifies how long the shutdown started by a signal (see
+	// InstallSignals) will wait for in-flight requests to complete before
+	// giving up on them. 0 means wait forever. a direct call to Shutdown
+	// does not use it; that wait is bounded by Shutdown's ctx.
+	LameDuck time.Duration
+	// User, Group - if User is set, the server binds to all Service
+	// addrs first, and then drops privileges to this user (and group,
+	// or the user's primary group if Group is empty) before serving
+	// any requests. this allows binding to privileged ports (eg. :80, :443)
+	// as root.
+	User     string
+	Group    string
 	logch    chan *Collect
 	errch    chan string
 	done     sync.WaitGroup
+	sigch    chan os.Signal
+	shutdown sync.Once
+	mwOnce   sync.Once
+	mw       []Middleware
+	handler  HandlerFunc
 }
 
 // Collect provides data to the statistics Collector
@@ -83,6 +130,9 @@ type Collect struct {
 	Size   int64
 	Status int
 	Usec   int
+	// Route is the pattern a Router matched the request against, eg
+	// "/users/:id", or Req.URL.Path if it was not routed through a Router.
+	Route string
 }
 type Collector func(*Collect)
 
@@ -90,6 +140,9 @@ type responseWriter struct {
 	w      http.ResponseWriter
 	size   int64
 	status int
+	// route is set by a Router, if s.Handler is one, to the pattern it
+	// matched - see Collect.Route.
+	route string
 }
 
 const logQueueSize = 1000
@@ -123,72 +176,162 @@ func (s *Server) Serve() {
 	s.newAccessLogger()
 	errz := s.newErrorLogger()
 
-	for i, _ := range s.Service {
+	// bind phase - acquire all of our listeners (incl. TLS) before
+	// we (optionally) drop privileges, so we can still bind to
+	// privileged ports as root
+	for i := range s.Service {
 		ss := &s.Service[i]
 
-		www := http.Server{
-			Addr:      ss.Addr,
-			Handler:   s,
-			TLSConfig: ss.TLSConfig,
-			ErrorLog:  errz, // why is this not simply an interface?
+		ln, err := net.Listen(listenNetwork(ss.Network), ss.Addr)
+		if err != nil {
+			s.Report.Fatal("enginz: cannot listen on '%s': %v", ss.Addr, err)
+			return
+		}
+
+		// either a tls.Config or a Key+Cert pair (or both)
+		if ss.TLSConfig != nil || (ss.TLSKey != "" && ss.TLSCert != "") {
+			conf := ss.TLSConfig
+			if ss.TLSKey != "" && ss.TLSCert != "" {
+				cert, err := tls.LoadX509KeyPair(ss.TLSCert, ss.TLSKey)
+				if err != nil {
+					s.Report.Fatal("enginz: cannot load tls cert for '%s': %v", ss.Addr, err)
+					return
+				}
+				if conf == nil {
+					conf = &tls.Config{}
+				} else {
+					conf = conf.Clone()
+				}
+				conf.Certificates = append(conf.Certificates, cert)
+			}
+			ss.TLSConfig = conf
+			ln = tls.NewListener(ln, conf)
 		}
 
-		ss.www = www
+		ss.ln = ln
+	}
+
+	if s.User != "" {
+		if err := s.dropPrivileges(); err != nil {
+			s.Report.Fatal("enginz: cannot drop privileges to user '%s': %v", s.User, err)
+			return
+		}
+	}
+
+	for i := range s.Service {
+		ss := &s.Service[i]
 
 		s.done.Add(1)
 
-		go func() {
-			defer s.done.Done()
-			// either a tls.Config or a Key+Cert pair (or both)
-			if ss.TLSConfig != nil || (ss.TLSKey != "" && ss.TLSCert != "") {
-				www.ListenAndServeTLS(ss.TLSCert, ss.TLSKey)
-			} else {
-				www.ListenAndServe()
+		if isFastCGI(ss.Network) {
+			// instrumentation (access log, recovery, headers, Collect)
+			// all run uniformly, since s itself is the http.Handler
+			go func(ss *Service) {
+				defer s.done.Done()
+				fcgi.Serve(ss.ln, s)
+			}(ss)
+			continue
+		}
+
+		ss.www.Handler = s
+		ss.www.TLSConfig = ss.TLSConfig
+		ss.www.ErrorLog = errz // why is this not simply an interface?
+
+		if ss.TLSConfig != nil {
+			// www.Serve is fed an already-TLS-wrapped listener (so we
+			// can bind privileged ports before dropping privileges),
+			// bypassing the http2 setup ListenAndServeTLS normally
+			// does - wire it up by hand, or TLS services silently lose h2.
+			if err := http2.ConfigureServer(&ss.www, nil); err != nil {
+				s.Report.Problem("enginz: cannot configure http2 for '%s': %v", ss.Addr, err)
 			}
-		}()
+		}
+
+		go func(ss *Service) {
+			defer s.done.Done()
+			ss.www.Serve(ss.ln)
+		}(ss)
 	}
 
 	s.done.Wait()
 }
 
-// Shutdown stops the server.
+// listenNetwork returns the net.Listen network for a Service.Network.
+func listenNetwork(network string) string {
+
+	switch network {
+	case "", "tcp":
+		return "tcp"
+	case "unix":
+		return "unix"
+	case "fcgi-tcp":
+		return "tcp"
+	case "fcgi-unix":
+		return "unix"
+	default:
+		return network
+	}
+}
+
+func isFastCGI(network string) bool {
+	return strings.HasPrefix(network, "fcgi-")
+}
+
+// Shutdown stops the server, waiting for in-flight requests to complete.
+// it is safe to call Shutdown more than once; only the first call has effect.
 // see also: net/http Shutdown()
 func (s *Server) Shutdown(ctx context.Context) {
 
-	var wg sync.WaitGroup
+	s.shutdown.Do(func() {
+		s.shutdownOnce(ctx)
+	})
+}
 
-	close(s.logch)
-	close(s.errch)
+func (s *Server) shutdownOnce(ctx context.Context) {
 
-	for _, ss := range s.Service {
+	var wg sync.WaitGroup
+
+	for i := range s.Service {
+		ss := &s.Service[i]
 		wg.Add(1)
-		go func() {
+		go func(ss *Service) {
 			defer wg.Done()
+			if isFastCGI(ss.Network) {
+				// fcgi.Serve has no graceful Shutdown; closing the
+				// listener is enough to make it return
+				if ss.ln != nil {
+					ss.ln.Close()
+				}
+				return
+			}
 			ss.www.Shutdown(ctx)
-		}()
+		}(ss)
 	}
 
+	// wait for all in-flight requests to be done being served before
+	// closing the log channels, so nothing tries to log on a closed channel
 	wg.Wait()
+
+	if s.logch != nil {
+		close(s.logch)
+	}
+	if s.errch != nil {
+		close(s.errch)
+	}
+
+	// let the logger goroutines drain whatever is left in the channels
+	s.done.Wait()
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
-	defer func() {
-		// because bugs
-		if r := recover(); r != nil {
-			s.Report.Problem("PANIC! %s -> %s\n%s\n", req.RemoteAddr, req.RequestURI, string(debug.Stack()))
-			s.serverError(w, req)
-		}
-	}()
+	s.mwOnce.Do(s.buildChain)
+	s.handler(w, req)
+}
 
-	// set headers
-	header := w.Header()
-	if s.ServerID != "" {
-		header.Set("Server", s.ServerID)
-	}
-	if s.TraceID != "" {
-		header.Set("X-Origin-Id", s.TraceID)
-	}
+// core runs the configured Handler, and times + logs + collects the result.
+// it is wrapped by the built-in and user Middleware - see buildChain.
+func (s *Server) core(w http.ResponseWriter, req *http.Request) {
 
 	lw := &responseWriter{w: w}
 
@@ -202,12 +345,18 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		lw.status = 200
 	}
 
+	route := lw.route
+	if route == "" {
+		route = req.URL.Path
+	}
+
 	// collect our stats
 	c := &Collect{
 		Usec:   int(dt.Nanoseconds() / 1000),
 		Size:   lw.size,
 		Status: lw.status,
 		Req:    req,
+		Route:  route,
 	}
 
 	// log it
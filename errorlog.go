@@ -37,13 +37,25 @@ func (s *Server) Error(b []byte) (int, error) {
 		s.Log.Verbose("%s", b)
 	}
 
-	if s.errch != nil {
-		s.errch <- string(b)
-	}
+	s.sendErr(string(b))
 
 	return len(b), nil
 }
 
+// sendErr delivers msg to errch, if any. Shutdown may close errch while
+// a late write from http.Server is in flight; recover rather than let
+// that panic take down the process.
+func (s *Server) sendErr(msg string) {
+
+	defer func() {
+		recover()
+	}()
+
+	if s.errch != nil {
+		s.errch <- msg
+	}
+}
+
 func (e engWrite) Write(b []byte) (int, error) {
 
 	return e.s.Error(b)
@@ -51,6 +63,8 @@ func (e engWrite) Write(b []byte) (int, error) {
 
 func (s *Server) errlogger() {
 
+	defer s.done.Done()
+
 	for {
 		select {
 		case msg, ok := <-s.errch:
@@ -0,0 +1,217 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: static file serving, hardened
+
+package enginz
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type fileServer struct {
+	root      string
+	noListing bool
+}
+
+// FileServerOption configures a FileServer.
+type FileServerOption func(*fileServer)
+
+// NoDirListing disables directory listings; a request for a directory
+// with no index.html returns 404 instead of a file listing.
+func NoDirListing() FileServerOption {
+	return func(fs *fileServer) { fs.noListing = true }
+}
+
+// FileServer returns a HandlerFunc that serves files out of root,
+// modeled on net/http.FileServer but hardened for mounting directly
+// into Routes or a Router:
+//
+//   - requests whose path contains ".." are rejected
+//   - a directory request serves its index.html, or (unless
+//     NoDirListing is given) a generated listing
+//   - Content-Type is set from the file extension
+//   - If-Modified-Since / If-None-Match are honored
+//   - a "foo.js.gz" sidecar is served, with Content-Encoding: gzip,
+//     when the client sends "Accept-Encoding: gzip" and it exists
+//
+// FileServer serves out of req.URL.Path as-is; to mount it under a
+// prefix, strip the prefix first with StripPrefix:
+//
+// var router = enginz.NewRouter()
+// router.Get("/static/*rest", enginz.StripPrefix("/static/", enginz.FileServer("/var/www/static")))
+//
+func FileServer(root string, opts ...FileServerOption) HandlerFunc {
+
+	fs := &fileServer{root: root}
+	for _, opt := range opts {
+		opt(fs)
+	}
+
+	return fs.serve
+}
+
+// StripPrefix returns a HandlerFunc that strips prefix from the request
+// URL's path before calling h, modeled on net/http.StripPrefix. requests
+// whose path does not have the prefix get a 404. use it to mount a
+// FileServer under a Router wildcard - see FileServer.
+func StripPrefix(prefix string, h HandlerFunc) HandlerFunc {
+
+	if prefix == "" {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		p := strings.TrimPrefix(req.URL.Path, prefix)
+		if len(p) == len(req.URL.Path) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "File Not Found. So Sorry.\n")
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = "/" + strings.TrimPrefix(p, "/")
+		h(w, r2)
+	}
+}
+
+func (fs *fileServer) serve(w http.ResponseWriter, req *http.Request) {
+
+	if strings.Contains(req.URL.Path, "..") {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "Forbidden.\n")
+		return
+	}
+
+	urlPath := path.Clean("/" + req.URL.Path)
+	file := filepath.Join(fs.root, filepath.FromSlash(urlPath))
+
+	info, err := os.Stat(file)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "File Not Found. So Sorry.\n")
+		return
+	}
+
+	if info.IsDir() {
+		idx := filepath.Join(file, "index.html")
+		if idxInfo, err := os.Stat(idx); err == nil && !idxInfo.IsDir() {
+			file, info = idx, idxInfo
+		} else if fs.noListing {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "File Not Found. So Sorry.\n")
+			return
+		} else {
+			serveDirListing(w, file, urlPath)
+			return
+		}
+	}
+
+	serveFile(w, req, file, info)
+}
+
+func serveDirListing(w http.ResponseWriter, dir, urlPath string) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "File Not Found. So Sorry.\n")
+		return
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	title := html.EscapeString(urlPath)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>%s</title></head><body><h1>%s</h1><ul>\n", title, title)
+	if urlPath != "/" {
+		fmt.Fprintf(w, "<li><a href=\"../\">..</a></li>\n")
+	}
+	for _, name := range names {
+		href := (&url.URL{Path: name}).String()
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", href, html.EscapeString(name))
+	}
+	fmt.Fprintf(w, "</ul></body></html>\n")
+}
+
+func serveFile(w http.ResponseWriter, req *http.Request, file string, info os.FileInfo) {
+
+	name := file
+	gzipped := false
+
+	// the response varies on Accept-Encoding whether or not a gzip
+	// sidecar actually exists, so a cache must always consult it
+	w.Header().Set("Vary", "Accept-Encoding")
+
+	if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		if gzInfo, err := os.Stat(file + ".gz"); err == nil && !gzInfo.IsDir() {
+			name, info, gzipped = file+".gz", gzInfo, true
+		}
+	}
+
+	// the gzip variant gets its own ETag, distinct from the plain
+	// file's, so a cache never serves one encoding's bytes for the other
+	etagSuffix := ""
+	if gzipped {
+		etagSuffix = "-gz"
+	}
+	etag := fmt.Sprintf(`"%x-%x%s"`, info.ModTime().Unix(), info.Size(), etagSuffix)
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" && inm == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !info.ModTime().Truncate(1e9).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "File Not Found. So Sorry.\n")
+		return
+	}
+	defer f.Close()
+
+	ctype := mime.TypeByExtension(filepath.Ext(file))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", ctype)
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+
+	io.Copy(w, f)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: graceful shutdown + signal handling
+
+package enginz
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// InstallSignals arranges for the server to shut down gracefully on
+// SIGINT, SIGTERM, or SIGHUP, and (where supported) to rotate the
+// access log on SIGUSR1.
+//
+// on a shutdown signal, the server stops accepting new connections and
+// waits up to Server.LameDuck for in-flight requests to finish before
+// returning control to the process (which typically then exits).
+func (s *Server) InstallSignals() {
+
+	if s.sigch != nil {
+		return
+	}
+
+	s.sigch = make(chan os.Signal, 1)
+	signal.Notify(s.sigch, shutdownSignals...)
+	if len(rotateSignals) > 0 {
+		// signal.Notify with no signals listed relays everything,
+		// which is not what an empty rotateSignals (eg. windows) means
+		signal.Notify(s.sigch, rotateSignals...)
+	}
+
+	go s.signalLoop()
+}
+
+func (s *Server) signalLoop() {
+
+	for sig := range s.sigch {
+
+		if isRotateSignal(sig) {
+			s.RotateLog()
+			continue
+		}
+
+		s.Report.Problem("enginz: caught signal %s, shutting down (lame-duck %s)", sig, s.LameDuck)
+
+		ctx := context.Background()
+		if s.LameDuck > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.LameDuck)
+			defer cancel()
+		}
+
+		s.Shutdown(ctx)
+		return
+	}
+}
+
+func isRotateSignal(sig os.Signal) bool {
+
+	for _, r := range rotateSignals {
+		if sig == r {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: json + custom access log formats
+
+package enginz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeLogJSON marshals msg with stable keys, suitable for feeding
+// into ELK/Loki/Splunk etc. one JSON object per line.
+func writeLogJSON(w io.Writer, s *Server, msg *Collect) {
+
+	req := msg.Req
+	header := req.Header
+
+	entry := map[string]interface{}{
+		"remote_addr":       req.RemoteAddr,
+		"time_rfc3339_nano": time.Now().Format(time.RFC3339Nano),
+		"host":              req.Host,
+		"status":            msg.Status,
+		"size":              msg.Size,
+		"duration_us":       msg.Usec,
+		"method":            req.Method,
+		"uri":               req.RequestURI,
+		"referer":           orDash(header.Get("Referer")),
+		"user_agent":        orDash(header.Get("User-Agent")),
+		"trace_id":          s.TraceID,
+	}
+
+	for _, f := range s.LogFields {
+		entry[jsonFieldName(f)] = header.Get(f)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	w.Write(b)
+	w.Write([]byte("\n"))
+}
+
+// jsonFieldName turns a header name like "X-Request-Id" into a snake_case
+// JSON key: "x_request_id".
+func jsonFieldName(header string) string {
+	return strings.ReplaceAll(strings.ToLower(header), "-", "_")
+}
+
+// writeLogCustom renders msg per an apache/nginx-style template - see
+// Server.LogTemplate for the supported tokens.
+func writeLogCustom(w io.Writer, s *Server, msg *Collect) {
+
+	req := msg.Req
+	tmpl := s.LogTemplate
+	var buf strings.Builder
+
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		if c != '%' || i+1 >= len(tmpl) {
+			buf.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch tmpl[i] {
+		case 'h':
+			buf.WriteString(req.RemoteAddr)
+		case 't':
+			buf.WriteString(time.Now().Format("2006-01-02T15:04:05"))
+		case 'v':
+			buf.WriteString(req.Host)
+		case 'm':
+			buf.WriteString(req.Method)
+		case 'U':
+			buf.WriteString(req.RequestURI)
+		case 'r':
+			fmt.Fprintf(&buf, "%s %s %s", req.Method, req.RequestURI, req.Proto)
+		case 's':
+			fmt.Fprintf(&buf, "%d", msg.Status)
+		case 'b':
+			fmt.Fprintf(&buf, "%d", msg.Size)
+		case 'D':
+			fmt.Fprintf(&buf, "%d", msg.Usec)
+		case '%':
+			buf.WriteByte('%')
+		case '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				buf.WriteString("%{")
+				continue
+			}
+			name := tmpl[i+1 : i+end]
+			i += end
+			if i+1 < len(tmpl) && tmpl[i+1] == 'i' {
+				i++
+				buf.WriteString(logEscape(orDash(req.Header.Get(name))))
+			}
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(tmpl[i])
+		}
+	}
+
+	buf.WriteByte('\n')
+	io.WriteString(w, buf.String())
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -15,7 +15,7 @@ import (
 
 func (s *Server) newAccessLogger() {
 
-	if s.AccessLog == "" {
+	if s.AccessLog == "" && s.AccessLogWriter == nil && s.AccessLogFunc == nil {
 		return
 	}
 
@@ -24,7 +24,9 @@ func (s *Server) newAccessLogger() {
 	go s.logger()
 }
 
-// RotateLog closes and reopens the log file
+// RotateLog closes and reopens the log file. it only has an effect when
+// logging to AccessLog (a file path); AccessLogWriter/AccessLogFunc sinks
+// are the caller's to manage.
 func (s *Server) RotateLog() {
 
 	if s.logch == nil {
@@ -58,16 +60,31 @@ func (s *Server) logger() {
 
 	defer s.done.Done()
 
-	// open file
-	w, err := os.OpenFile(s.AccessLog, os.O_APPEND|os.O_WRONLY|os.O_CREATE /*sic*/, 0666)
-
-	if err != nil {
-		s.Report.Fatal("cannot open log file '%s': %v", s.AccessLog, err)
+	if s.AccessLogFunc != nil {
+		s.funcLogger()
 		return
 	}
 
+	// open file, unless a Writer was given
+	var w io.Writer
+	var file *os.File
+
+	if s.AccessLogWriter != nil {
+		w = s.AccessLogWriter
+	} else {
+		f, err := os.OpenFile(s.AccessLog, os.O_APPEND|os.O_WRONLY|os.O_CREATE /*sic*/, 0666)
+		if err != nil {
+			s.Report.Fatal("cannot open log file '%s': %v", s.AccessLog, err)
+			return
+		}
+		file = f
+		w = f
+	}
+
 	defer func() {
-		w.Close()
+		if file != nil {
+			file.Close()
+		}
 	}()
 
 	for {
@@ -77,30 +94,58 @@ func (s *Server) logger() {
 				return
 			}
 			if msg.Req == nil {
-				// rotate log
-				wx, err := os.OpenFile(s.AccessLog, os.O_APPEND|os.O_WRONLY|os.O_CREATE /*sic*/, 0666)
-				if err != nil {
-					s.Report.Problem("cannot open log file '%s': %v", s.AccessLog, err)
-				} else {
-					w.Close()
-					w = wx
+				// rotate log. only meaningful when we opened the file ourselves
+				if file != nil {
+					wx, err := os.OpenFile(s.AccessLog, os.O_APPEND|os.O_WRONLY|os.O_CREATE /*sic*/, 0666)
+					if err != nil {
+						s.Report.Problem("cannot open log file '%s': %v", s.AccessLog, err)
+					} else {
+						file.Close()
+						file, w = wx, wx
+					}
 				}
 				continue
 			}
 
-			writeLog(w, msg)
+			s.writeLogEntry(w, msg)
 		}
 	}
 }
 
-// AC log format. similar to:
+func (s *Server) funcLogger() {
+
+	for msg := range s.logch {
+		if msg.Req == nil {
+			// rotate signal, meaningless for an AccessLogFunc sink
+			continue
+		}
+
+		s.AccessLogFunc(msg)
+	}
+}
+
+// writeLogEntry formats msg per s.LogFormat and writes it to w.
+func (s *Server) writeLogEntry(w io.Writer, msg *Collect) {
+
+	switch s.LogFormat {
+	case "json":
+		writeLogJSON(w, s, msg)
+	case "custom":
+		writeLogCustom(w, s, msg)
+	default:
+		writeLogCombined(w, s, msg)
+	}
+}
+
+// combined log format. similar to:
 //  apache: '$remote_addr - $msec $http_host $status $body_bytes_sent $request_time "$request" "$http_referer" "$http_user_agent"';
 //  nginx:  "%h %c %{%Y-%m-%dT%H:%M:%S}t %v %>s %b %P %T \"%r\" \"%{Referer}i\" \"%{User-Agent}i\"" combined
 //
-// no, you cannot change the format, but it is simple to parse. break out your perl/sed/awk
+// this is the default, and what you get when LogFormat is unset - see
+// LogFormat for "json" and "custom" alternatives.
 // all fields which can contain whitespace are percent-encoded, so you can simply split on space (how cool is that!)
 
-func writeLog(w io.Writer, msg *Collect) {
+func writeLogCombined(w io.Writer, s *Server, msg *Collect) {
 
 	req := msg.Req
 	header := req.Header
@@ -114,12 +159,12 @@ func writeLog(w io.Writer, msg *Collect) {
 		ua = "-"
 	}
 
+	// LogFields only applies to "json" - see Server.LogFields.
 	fmt.Fprintf(w, "%s - %s %s %d %d %d %s \"%s\" \"%s\" \"%s\"\n",
 		req.RemoteAddr, time.Now().Format("2006-01-02T15:04:05"), req.Host,
 		msg.Status, msg.Size, msg.Usec,
 		req.Method, req.RequestURI,
 		logEscape(rfr), logEscape(ua))
-
 }
 
 func verboseLog(dl Logger, msg *Collect) {
@@ -0,0 +1,108 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: middleware chain
+
+package enginz
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// Middleware wraps a HandlerFunc to add cross-cutting behavior, eg
+// auth, gzip, request-id propagation, recovery, etc.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Use registers Middleware to wrap the Server's Handler, in addition to
+// the built-in panic-recovery and Server/TraceID header middleware.
+// middleware runs in the order given, each one wrapping the next, inside
+// the built-in middleware. the last one given wraps the step that times
+// the Handler and logs + Collects the result, so a middleware that
+// answers a request without calling next leaves no access log entry
+// and no Collect call for it.
+//
+// Use must be called before the Server starts serving requests.
+func (s *Server) Use(mw ...Middleware) {
+	s.mw = append(s.mw, mw...)
+}
+
+// buildChain composes the built-in middleware and any added via Use
+// around s.core, producing the handler used by ServeHTTP. the default
+// chain (no Use calls) is identical to the pre-Middleware behavior.
+func (s *Server) buildChain() {
+
+	chain := append([]Middleware{
+		recoverMiddleware(s),
+		serverIDMiddleware(s),
+		traceIDMiddleware(s),
+	}, s.mw...)
+
+	h := HandlerFunc(s.core)
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+	}
+
+	s.handler = h
+}
+
+// Chain composes mw around h, in the order given, for use as a single
+// per-route HandlerFunc, eg:
+//
+//   var router = enginz.Routes{
+//       "/admin": enginz.Chain(adminPage, requireAuth),
+//   }
+//
+func Chain(h HandlerFunc, mw ...Middleware) HandlerFunc {
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+
+	return h
+}
+
+// recoverMiddleware is the built-in panic catcher, promoted from
+// ServeHTTP. it always runs outermost, ahead of the Server and
+// X-Origin-Id header middleware; Use only adds middleware inside these
+// three, and cannot reorder or replace them.
+func recoverMiddleware(s *Server) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			defer func() {
+				// because bugs
+				if r := recover(); r != nil {
+					s.Report.Problem("PANIC! %s -> %s\n%s\n", req.RemoteAddr, req.RequestURI, string(debug.Stack()))
+					s.serverError(w, req)
+				}
+			}()
+
+			next(w, req)
+		}
+	}
+}
+
+// serverIDMiddleware sets the Server header, promoted from ServeHTTP.
+func serverIDMiddleware(s *Server) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			if s.ServerID != "" {
+				w.Header().Set("Server", s.ServerID)
+			}
+			next(w, req)
+		}
+	}
+}
+
+// traceIDMiddleware sets the X-Origin-Id header, promoted from ServeHTTP.
+func traceIDMiddleware(s *Server) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			if s.TraceID != "" {
+				w.Header().Set("X-Origin-Id", s.TraceID)
+			}
+			next(w, req)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: drop privileges after bind
+
+//go:build !windows
+
+package enginz
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// dropPrivileges switches the process to s.User (and s.Group, or the
+// user's primary group if unset). it is only meaningful once we are
+// already bound to our listening sockets.
+func (s *Server) dropPrivileges() error {
+
+	u, err := user.Lookup(s.User)
+	if err != nil {
+		return fmt.Errorf("cannot find user '%s': %w", s.User, err)
+	}
+
+	gid := u.Gid
+	if s.Group != "" {
+		g, err := user.LookupGroup(s.Group)
+		if err != nil {
+			return fmt.Errorf("cannot find group '%s': %w", s.Group, err)
+		}
+		gid = g.Gid
+	}
+
+	gidn, err := strconv.Atoi(gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid '%s': %w", gid, err)
+	}
+	uidn, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid '%s': %w", u.Uid, err)
+	}
+
+	// group first - we cannot change it once we are no longer root
+	if err := syscall.Setgid(gidn); err != nil {
+		return fmt.Errorf("setgid(%d): %w", gidn, err)
+	}
+	if err := syscall.Setuid(uidn); err != nil {
+		return fmt.Errorf("setuid(%d): %w", uidn, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: drop privileges after bind - windows has no setuid/setgid
+
+//go:build windows
+
+package enginz
+
+import "fmt"
+
+func (s *Server) dropPrivileges() error {
+	return fmt.Errorf("enginz: Server.User is not supported on windows")
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: prometheus/openmetrics stats collector
+
+package enginz
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const promShardCount = 16
+
+// promBucketBoundsUsec are the upper bounds (in microseconds) of the
+// request-duration histogram buckets, ie 1ms, 5ms, 25ms, 100ms, 500ms,
+// 2500ms. samples above the last bound fall into the implicit +Inf bucket.
+var promBucketBoundsUsec = [...]uint64{1000, 5000, 25000, 100000, 500000, 2500000}
+
+type promStat struct {
+	method  string
+	status  int
+	route   string
+	count   uint64
+	sumUsec uint64
+	buckets [len(promBucketBoundsUsec) + 1]uint64
+}
+
+type promShard struct {
+	mu    sync.Mutex
+	stats map[string]*promStat
+}
+
+// PromCollector is a Collector that aggregates request counts and a
+// request-duration histogram per (method, status, route), in
+// Prometheus/OpenMetrics friendly form. the shard lock is only taken
+// to find or create a (method, status, route)'s *promStat; the counts
+// themselves are updated with atomics, off the hot path's lock.
+//
+// pc := enginz.NewPromCollector()
+// server.Collect = pc.Collect
+// router.Get("/metrics", enginz.MetricsHandler(pc))
+//
+type PromCollector struct {
+	shards [promShardCount]promShard
+}
+
+// NewPromCollector creates an empty PromCollector.
+func NewPromCollector() *PromCollector {
+
+	pc := &PromCollector{}
+	for i := range pc.shards {
+		pc.shards[i].stats = make(map[string]*promStat)
+	}
+
+	return pc
+}
+
+// Collect implements Collector.
+func (pc *PromCollector) Collect(c *Collect) {
+
+	route := c.Route
+	method := c.Req.Method
+	key := method + "\x00" + strconv.Itoa(c.Status) + "\x00" + route
+
+	shard := &pc.shards[promShardFor(key)]
+
+	shard.mu.Lock()
+	st, ok := shard.stats[key]
+	if !ok {
+		st = &promStat{method: method, status: c.Status, route: route}
+		shard.stats[key] = st
+	}
+	shard.mu.Unlock()
+
+	atomic.AddUint64(&st.count, 1)
+	atomic.AddUint64(&st.sumUsec, uint64(c.Usec))
+	atomic.AddUint64(&st.buckets[promBucketIndex(c.Usec)], 1)
+}
+
+func promShardFor(key string) uint32 {
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % promShardCount
+}
+
+func promBucketIndex(usec int) int {
+
+	for i, bound := range promBucketBoundsUsec {
+		if uint64(usec) <= bound {
+			return i
+		}
+	}
+
+	return len(promBucketBoundsUsec)
+}
+
+// MetricsHandler renders pc's stats in OpenMetrics text exposition format.
+func MetricsHandler(pc *PromCollector) HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		pc.writeTo(w)
+	}
+}
+
+func (pc *PromCollector) writeTo(w http.ResponseWriter) {
+
+	var all []*promStat
+	for i := range pc.shards {
+		shard := &pc.shards[i]
+		shard.mu.Lock()
+		for _, st := range shard.stats {
+			all = append(all, st)
+		}
+		shard.mu.Unlock()
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].route != all[j].route {
+			return all[i].route < all[j].route
+		}
+		if all[i].method != all[j].method {
+			return all[i].method < all[j].method
+		}
+		return all[i].status < all[j].status
+	})
+
+	// OpenMetrics counter family names are declared without the
+	// "_total" suffix; it appears only on the sample lines below.
+	fmt.Fprintf(w, "# HELP enginz_http_requests Total number of HTTP requests.\n")
+	fmt.Fprintf(w, "# TYPE enginz_http_requests counter\n")
+	for _, st := range all {
+		fmt.Fprintf(w, "enginz_http_requests_total{method=%q,status=%q,route=%q} %d\n",
+			st.method, strconv.Itoa(st.status), st.route, atomic.LoadUint64(&st.count))
+	}
+
+	fmt.Fprintf(w, "# HELP enginz_http_request_duration_seconds HTTP request duration in seconds.\n")
+	fmt.Fprintf(w, "# TYPE enginz_http_request_duration_seconds histogram\n")
+	for _, st := range all {
+		var cum uint64
+		for i, bound := range promBucketBoundsUsec {
+			cum += atomic.LoadUint64(&st.buckets[i])
+			fmt.Fprintf(w, "enginz_http_request_duration_seconds_bucket{method=%q,status=%q,route=%q,le=%q} %d\n",
+				st.method, strconv.Itoa(st.status), st.route, promSeconds(bound), cum)
+		}
+		cum += atomic.LoadUint64(&st.buckets[len(promBucketBoundsUsec)])
+		fmt.Fprintf(w, "enginz_http_request_duration_seconds_bucket{method=%q,status=%q,route=%q,le=\"+Inf\"} %d\n",
+			st.method, strconv.Itoa(st.status), st.route, cum)
+		fmt.Fprintf(w, "enginz_http_request_duration_seconds_sum{method=%q,status=%q,route=%q} %s\n",
+			st.method, strconv.Itoa(st.status), st.route, promSeconds(atomic.LoadUint64(&st.sumUsec)))
+		fmt.Fprintf(w, "enginz_http_request_duration_seconds_count{method=%q,status=%q,route=%q} %d\n",
+			st.method, strconv.Itoa(st.status), st.route, atomic.LoadUint64(&st.count))
+	}
+
+	fmt.Fprintf(w, "# EOF\n")
+}
+
+func promSeconds(usec uint64) string {
+	return strconv.FormatFloat(float64(usec)/1e6, 'g', -1, 64)
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: router with method dispatch + path params
+
+package enginz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type paramsKeyType struct{}
+type routeKeyType struct{}
+
+var paramsKey = paramsKeyType{}
+var routeKey = routeKeyType{}
+
+type routeParam struct {
+	Key   string
+	Value string
+}
+
+// Params holds the path parameters matched by a Router.
+type Params []routeParam
+
+// Get returns the value of the named parameter, or "" if it is not present.
+func (p Params) Get(name string) string {
+
+	for _, kv := range p {
+		if kv.Key == name {
+			return kv.Value
+		}
+	}
+
+	return ""
+}
+
+// Param returns the value of the named path parameter for req, as
+// matched by a Router. it returns "" if req was not routed through a
+// Router, or the parameter is not present.
+func Param(req *http.Request, name string) string {
+
+	ps, _ := req.Context().Value(paramsKey).(Params)
+	return ps.Get(name)
+}
+
+// RouteTemplate returns the pattern a Router matched req against, eg
+// "/users/:id", for use by things like a stats Collector that want to
+// aggregate by route without an explosion of distinct URIs. it returns
+// req.URL.Path if req was not routed through a Router.
+func RouteTemplate(req *http.Request) string {
+
+	if rt, ok := req.Context().Value(routeKey).(string); ok {
+		return rt
+	}
+
+	return req.URL.Path
+}
+
+// routeNode is one segment of the tree. a segment is either a static
+// string, a ":name" param, or a "*name" wildcard that matches the rest
+// of the path.
+type routeNode struct {
+	static       map[string]*routeNode
+	param        *routeNode
+	paramName    string
+	wildcard     *routeNode
+	wildcardName string
+	handlers     map[string]HandlerFunc
+	pattern      string
+}
+
+// Router implements a HandlerFunc router with per-method dispatch and
+// named path parameters.
+//
+// var router = enginz.NewRouter()
+// router.Get("/users/:id", getUser)
+// router.Get("/users/:id/posts/*rest", getUserPosts)
+// router.Post("/users", createUser)
+//
+// func getUser(w http.ResponseWriter, req *http.Request) {
+//     id := enginz.Param(req, "id")
+//     ...
+// }
+//
+type Router struct {
+	root *routeNode
+	// NotFound is invoked when no route matches the request path.
+	// defaults to a plain 404.
+	NotFound HandlerFunc
+	// MethodNotAllowed is invoked when the path matches a registered
+	// route, but not for the request's method. defaults to a plain 405.
+	MethodNotAllowed HandlerFunc
+}
+
+// NewRouter creates an empty Router.
+func NewRouter() *Router {
+	return &Router{root: &routeNode{}}
+}
+
+// Handle registers h to serve method requests matching pattern, wrapped
+// by any given per-route mw. eg:
+//
+//   router.Handle("GET", "/users/:id", getUser)
+//   router.Handle("GET", "/admin", adminPage, requireAuth)
+//
+func (rt *Router) Handle(method, pattern string, h HandlerFunc, mw ...Middleware) {
+
+	if rt.root == nil {
+		rt.root = &routeNode{}
+	}
+
+	h = Chain(h, mw...)
+	n := rt.root
+
+	for _, seg := range splitPath(pattern) {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			if n.param == nil {
+				n.param = &routeNode{}
+				n.paramName = seg[1:]
+			}
+			n = n.param
+
+		case strings.HasPrefix(seg, "*"):
+			if n.wildcard == nil {
+				n.wildcard = &routeNode{}
+				n.wildcardName = seg[1:]
+			}
+			n = n.wildcard
+
+		default:
+			if n.static == nil {
+				n.static = make(map[string]*routeNode)
+			}
+			c, ok := n.static[seg]
+			if !ok {
+				c = &routeNode{}
+				n.static[seg] = c
+			}
+			n = c
+		}
+	}
+
+	if n.handlers == nil {
+		n.handlers = make(map[string]HandlerFunc)
+	}
+
+	n.handlers[method] = h
+	n.pattern = pattern
+}
+
+// Get registers a handler for GET requests matching pattern.
+func (rt *Router) Get(pattern string, h HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodGet, pattern, h, mw...)
+}
+
+// Post registers a handler for POST requests matching pattern.
+func (rt *Router) Post(pattern string, h HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodPost, pattern, h, mw...)
+}
+
+// Put registers a handler for PUT requests matching pattern.
+func (rt *Router) Put(pattern string, h HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodPut, pattern, h, mw...)
+}
+
+// Delete registers a handler for DELETE requests matching pattern.
+func (rt *Router) Delete(pattern string, h HandlerFunc, mw ...Middleware) {
+	rt.Handle(http.MethodDelete, pattern, h, mw...)
+}
+
+func splitPath(p string) []string {
+
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+// match resolves segs against n, trying the static, param, then wildcard
+// branches in that order, and backtracking to a less specific branch
+// when a more specific one turns out to be a dead end (a subtree with
+// no registered route for the full remaining path). it returns the
+// matched node and the params collected along the way, or nil if
+// nothing under n matches segs.
+func (n *routeNode) match(segs []string, params Params) (*routeNode, Params) {
+
+	if len(segs) == 0 {
+		if n.pattern != "" {
+			return n, params
+		}
+		return nil, nil
+	}
+
+	seg, rest := segs[0], segs[1:]
+
+	if c, ok := n.static[seg]; ok {
+		if m, p := c.match(rest, params); m != nil {
+			return m, p
+		}
+	}
+
+	if n.param != nil {
+		if m, p := n.param.match(rest, appendParam(params, n.paramName, seg)); m != nil {
+			return m, p
+		}
+	}
+
+	if n.wildcard != nil {
+		return n.wildcard, appendParam(params, n.wildcardName, strings.Join(segs, "/"))
+	}
+
+	return nil, nil
+}
+
+func appendParam(params Params, key, value string) Params {
+
+	p := make(Params, len(params), len(params)+1)
+	copy(p, params)
+	return append(p, routeParam{Key: key, Value: value})
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	n, params := rt.root.match(splitPath(req.URL.Path), nil)
+	if n == nil {
+		rt.notFound(w, req)
+		return
+	}
+
+	h, ok := n.handlers[req.Method]
+	if !ok {
+		rt.methodNotAllowed(w, req)
+		return
+	}
+
+	if rw, ok := w.(*responseWriter); ok {
+		rw.route = n.pattern
+	}
+
+	ctx := req.Context()
+	if len(params) > 0 {
+		ctx = context.WithValue(ctx, paramsKey, params)
+	}
+	ctx = context.WithValue(ctx, routeKey, n.pattern)
+	req = req.WithContext(ctx)
+
+	h(w, req)
+}
+
+func (rt *Router) notFound(w http.ResponseWriter, req *http.Request) {
+
+	if rt.NotFound != nil {
+		rt.NotFound(w, req)
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, "File Not Found. So Sorry.\n")
+}
+
+func (rt *Router) methodNotAllowed(w http.ResponseWriter, req *http.Request) {
+
+	if rt.MethodNotAllowed != nil {
+		rt.MethodNotAllowed(w, req)
+		return
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	fmt.Fprintf(w, "Method Not Allowed.\n")
+}
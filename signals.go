@@ -0,0 +1,16 @@
+// Copyright (c) 2018
+// Author: Jeff Weisberg <jaw @ tcp4me.com>
+// Created: 2018-Dec-11 15:40 (EST)
+// Function: the signals we catch - unix has more of them than windows
+
+//go:build !windows
+
+package enginz
+
+import (
+	"os"
+	"syscall"
+)
+
+var shutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+var rotateSignals = []os.Signal{syscall.SIGUSR1}